@@ -0,0 +1,132 @@
+package mw
+
+import (
+	"net/http"
+	"strings"
+)
+
+// bearerSourceKind identifies where a bearer token may be read from.
+type bearerSourceKind int
+
+const (
+	bearerSourceHeader bearerSourceKind = iota
+	bearerSourceQuery
+	bearerSourceForm
+	bearerSourceCookie
+)
+
+// bearerSource is a single place ExtractBearer looks for a token, tried in
+// the order the matching options were given.
+type bearerSource struct {
+	kind bearerSourceKind
+	name string
+}
+
+// bearerConfig holds the sources collected from BearerOption values.
+type bearerConfig struct {
+	sources []bearerSource
+}
+
+// BearerOption enables a source ExtractBearer will look for a token in.
+type BearerOption func(*bearerConfig)
+
+// WithHeader enables reading the token from the `Authorization: Bearer
+// <token>` header (RFC 6750 section 2.1).
+func WithHeader() BearerOption {
+	return func(c *bearerConfig) {
+		c.sources = append(c.sources, bearerSource{kind: bearerSourceHeader})
+	}
+}
+
+// WithQueryParam enables reading the token from the named URL query
+// parameter (RFC 6750 section 2.3). The RFC recommends against this source
+// for anything but development use, since URLs tend to end up in logs.
+func WithQueryParam(name string) BearerOption {
+	return func(c *bearerConfig) {
+		c.sources = append(c.sources, bearerSource{kind: bearerSourceQuery, name: name})
+	}
+}
+
+// WithFormParam enables reading the token from the named
+// application/x-www-form-urlencoded body parameter (RFC 6750 section 2.2).
+// Enabling this is the only thing that triggers r.ParseForm, and only when
+// this source is actually reached.
+func WithFormParam(name string) BearerOption {
+	return func(c *bearerConfig) {
+		c.sources = append(c.sources, bearerSource{kind: bearerSourceForm, name: name})
+	}
+}
+
+// WithCookie enables reading the token from the named cookie.
+func WithCookie(name string) BearerOption {
+	return func(c *bearerConfig) {
+		c.sources = append(c.sources, bearerSource{kind: bearerSourceCookie, name: name})
+	}
+}
+
+// ExtractBearer looks up a bearer token using the given sources, tried in
+// order; it defaults to the Authorization header alone when no options are
+// given. The header source strictly parses the `Bearer` scheme (case
+// insensitive) and rejects an empty token; the other sources return the
+// parameter value verbatim, as RFC 6750 doesn't prefix those with a scheme.
+func ExtractBearer(r *http.Request, opts ...BearerOption) (string, bool) {
+	cfg := bearerConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if len(cfg.sources) == 0 {
+		cfg.sources = []bearerSource{{kind: bearerSourceHeader}}
+	}
+
+	for _, src := range cfg.sources {
+		switch src.kind {
+		case bearerSourceHeader:
+			if token, ok := bearerFromHeader(r); ok {
+				return token, true
+			}
+		case bearerSourceQuery:
+			if token := r.URL.Query().Get(src.name); token != "" {
+				return token, true
+			}
+		case bearerSourceForm:
+			if err := r.ParseForm(); err != nil {
+				continue
+			}
+			if token := r.PostForm.Get(src.name); token != "" {
+				return token, true
+			}
+		case bearerSourceCookie:
+			if cookie, err := r.Cookie(src.name); err == nil && cookie.Value != "" {
+				return cookie.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// bearerHeaderScheme is the RFC 6750 section 2.1 scheme name, matched
+// case-insensitively.
+const bearerHeaderScheme = "bearer "
+
+// bearerFromHeader extracts the token from a strict `Authorization: Bearer
+// <token>` header, rejecting any other scheme and empty tokens.
+func bearerFromHeader(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(bearerHeaderScheme) || !strings.EqualFold(header[:len(bearerHeaderScheme)], bearerHeaderScheme) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(bearerHeaderScheme):])
+	if token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// Bearer gets the bearer token out of a given request object, trying the
+// Authorization header, then the legacy `Authorization` query parameter,
+// then the legacy `Authorization` form parameter, for backward
+// compatibility with callers of the original Bearer. New code should call
+// ExtractBearer directly with the sources it actually needs.
+func Bearer(r *http.Request) (string, bool) {
+	return ExtractBearer(r, WithHeader(), WithQueryParam(jwtAuthKey), WithFormParam(jwtAuthKey))
+}
@@ -0,0 +1,121 @@
+package mw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtractBearerHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantToken string
+		wantOK    bool
+	}{
+		{"well formed", "Bearer abc123", "abc123", true},
+		{"case-insensitive scheme", "bearer abc123", "abc123", true},
+		{"extra whitespace after scheme", "Bearer   abc123", "abc123", true},
+		{"empty token is rejected", "Bearer ", "", false},
+		{"wrong scheme is rejected", "Basic abc123", "", false},
+		{"no header", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			token, ok := ExtractBearer(req, WithHeader())
+			if ok != tt.wantOK || token != tt.wantToken {
+				t.Fatalf("ExtractBearer() = (%q, %v), want (%q, %v)", token, ok, tt.wantToken, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExtractBearerQueryParam(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?access_token=abc123", nil)
+	token, ok := ExtractBearer(req, WithQueryParam("access_token"))
+	if !ok || token != "abc123" {
+		t.Fatalf("ExtractBearer() = (%q, %v), want (\"abc123\", true)", token, ok)
+	}
+
+	// a source that isn't enabled must not be consulted
+	if _, ok := ExtractBearer(req, WithHeader()); ok {
+		t.Fatal("expected query param to be ignored when only WithHeader is set")
+	}
+}
+
+func TestExtractBearerFormParamOnlyParsesFormWhenEnabled(t *testing.T) {
+	form := url.Values{"access_token": {"abc123"}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if _, ok := ExtractBearer(req, WithHeader()); ok {
+		t.Fatal("expected form param to be ignored when only WithHeader is set")
+	}
+	if req.PostForm != nil {
+		t.Fatal("ParseForm must not run unless WithFormParam is enabled")
+	}
+
+	token, ok := ExtractBearer(req, WithFormParam("access_token"))
+	if !ok || token != "abc123" {
+		t.Fatalf("ExtractBearer() = (%q, %v), want (\"abc123\", true)", token, ok)
+	}
+}
+
+func TestExtractBearerCookie(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc123"})
+
+	token, ok := ExtractBearer(req, WithCookie("session"))
+	if !ok || token != "abc123" {
+		t.Fatalf("ExtractBearer() = (%q, %v), want (\"abc123\", true)", token, ok)
+	}
+}
+
+func TestExtractBearerSourceOrder(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?access_token=from-query", nil)
+	req.Header.Set("Authorization", "Bearer from-header")
+
+	token, ok := ExtractBearer(req, WithQueryParam("access_token"), WithHeader())
+	if !ok || token != "from-query" {
+		t.Fatalf("expected the first configured source (query) to win, got (%q, %v)", token, ok)
+	}
+}
+
+func TestBearerLegacyBackwardCompatibility(t *testing.T) {
+	t.Run("header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer abc123")
+		if token, ok := Bearer(req); !ok || token != "abc123" {
+			t.Fatalf("Bearer() = (%q, %v), want (\"abc123\", true)", token, ok)
+		}
+	})
+
+	t.Run("legacy Authorization query param", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?Authorization=abc123", nil)
+		if token, ok := Bearer(req); !ok || token != "abc123" {
+			t.Fatalf("Bearer() = (%q, %v), want (\"abc123\", true)", token, ok)
+		}
+	})
+
+	t.Run("legacy Authorization form param", func(t *testing.T) {
+		form := url.Values{"Authorization": {"abc123"}}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token, ok := Bearer(req); !ok || token != "abc123" {
+			t.Fatalf("Bearer() = (%q, %v), want (\"abc123\", true)", token, ok)
+		}
+	})
+
+	t.Run("no source set", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if _, ok := Bearer(req); ok {
+			t.Fatal("expected Bearer() to report no token found")
+		}
+	})
+}
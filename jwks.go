@@ -0,0 +1,471 @@
+package mw
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// defaultJWKSCacheTTL is how long fetched keys are trusted before a refresh
+// is attempted.
+const defaultJWKSCacheTTL = 5 * time.Minute
+
+// defaultJWKSPath is appended to the issuer URL when no explicit JWKS URL is
+// configured.
+const defaultJWKSPath = "/.well-known/jwks.json"
+
+// jwk is a single entry of a JSON Web Key Set, as used by RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// cachedJWK is a parsed public key together with the algorithm it was
+// published for, used to reject alg-confusion attacks.
+type cachedJWK struct {
+	alg       string
+	key       interface{}
+	fetchedAt time.Time
+}
+
+// jwksConfig holds the options collected from JWKSOption values.
+type jwksConfig struct {
+	jwksURL    string
+	httpClient *http.Client
+	cacheTTL   time.Duration
+	clockSkew  time.Duration
+	presetKeys map[string]JWKSPresetKey
+}
+
+// JWKSOption configures JwtJWKS.
+type JWKSOption func(*jwksConfig)
+
+// WithJWKSURL overrides the default `<issuer>/.well-known/jwks.json`
+// endpoint with an explicit URL.
+func WithJWKSURL(url string) JWKSOption {
+	return func(c *jwksConfig) { c.jwksURL = url }
+}
+
+// WithJWKSHTTPClient plugs in a custom http.Client, e.g. for proxies or
+// custom TLS configuration.
+func WithJWKSHTTPClient(client *http.Client) JWKSOption {
+	return func(c *jwksConfig) { c.httpClient = client }
+}
+
+// WithJWKSCacheTTL overrides how long a fetched key is trusted before being
+// refreshed from the issuer.
+func WithJWKSCacheTTL(ttl time.Duration) JWKSOption {
+	return func(c *jwksConfig) { c.cacheTTL = ttl }
+}
+
+// WithClockSkew widens exp/nbf/iat validation by the given duration, to
+// tolerate clock drift between the issuer and this service.
+func WithClockSkew(d time.Duration) JWKSOption {
+	return func(c *jwksConfig) { c.clockSkew = d }
+}
+
+// JWKSPresetKey is a single key injected via WithKeySet. Algorithm must be
+// set to one of the jwksAlgMethods names (e.g. "RS256") so the alg-confusion
+// guard in JwtJWKS's Keyfunc still applies to preset keys exactly as it does
+// to keys fetched from the network.
+type JWKSPresetKey struct {
+	Algorithm string
+	Key       interface{}
+}
+
+// WithKeySet injects a pre-built key set instead of fetching it from the
+// network, intended for tests.
+func WithKeySet(keys map[string]JWKSPresetKey) JWKSOption {
+	return func(c *jwksConfig) {
+		c.presetKeys = keys
+	}
+}
+
+// jwksProvider fetches and caches keys from a remote JWKS endpoint, keyed by
+// `kid`. Concurrent misses for the same `kid` are coalesced so a burst of
+// unknown-kid tokens only triggers a single refresh.
+type jwksProvider struct {
+	url        string
+	httpClient *http.Client
+	cacheTTL   time.Duration
+
+	mu    sync.RWMutex
+	cache map[string]cachedJWK
+
+	presetKeys map[string]JWKSPresetKey
+
+	flightMu sync.Mutex
+	flight   map[string]*jwksCall
+}
+
+// jwksCall represents an in-flight (or completed) refresh of the whole key
+// set, shared by every concurrent caller that missed the cache, whatever kid
+// it was looking for.
+type jwksCall struct {
+	done chan struct{}
+	key  cachedJWK
+	err  error
+}
+
+func newJWKSProvider(cfg jwksConfig) *jwksProvider {
+	return &jwksProvider{
+		url:        cfg.jwksURL,
+		httpClient: cfg.httpClient,
+		cacheTTL:   cfg.cacheTTL,
+		cache:      make(map[string]cachedJWK),
+		presetKeys: cfg.presetKeys,
+		flight:     make(map[string]*jwksCall),
+	}
+}
+
+// keyForKID returns the public key and declared algorithm for the given kid,
+// fetching (or refreshing) the JWKS document when the cache is empty, stale,
+// or missing the kid.
+func (p *jwksProvider) keyForKID(kid string) (interface{}, string, error) {
+	if p.presetKeys != nil {
+		preset, ok := p.presetKeys[kid]
+		if !ok {
+			return nil, "", fmt.Errorf("jwks: no key found for kid %q", kid)
+		}
+		return preset.Key, preset.Algorithm, nil
+	}
+
+	p.mu.RLock()
+	cached, ok := p.cache[kid]
+	p.mu.RUnlock()
+	if ok && time.Since(cached.fetchedAt) < p.cacheTTL {
+		return cached.key, cached.alg, nil
+	}
+
+	call := p.startRefresh()
+	<-call.done
+	if call.err != nil {
+		return nil, "", call.err
+	}
+
+	p.mu.RLock()
+	cached, ok = p.cache[kid]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("jwks: no key found for kid %q", kid)
+	}
+	return cached.key, cached.alg, nil
+}
+
+// startRefresh coalesces concurrent refreshes into a single request: the
+// first caller performs the fetch, everyone else waits on its result.
+func (p *jwksProvider) startRefresh() *jwksCall {
+	const flightKey = "refresh"
+
+	p.flightMu.Lock()
+	if call, ok := p.flight[flightKey]; ok {
+		p.flightMu.Unlock()
+		return call
+	}
+	call := &jwksCall{done: make(chan struct{})}
+	p.flight[flightKey] = call
+	p.flightMu.Unlock()
+
+	go func() {
+		defer close(call.done)
+		defer func() {
+			p.flightMu.Lock()
+			delete(p.flight, flightKey)
+			p.flightMu.Unlock()
+		}()
+		call.err = p.fetch()
+	}()
+
+	return call
+}
+
+func (p *jwksProvider) fetch() error {
+	resp, err := p.httpClient.Get(p.url)
+	if err != nil {
+		return fmt.Errorf("jwks: fetching %s: %w", p.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks: fetching %s: unexpected status %d", p.url, resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("jwks: decoding %s: %w", p.url, err)
+	}
+
+	now := time.Now()
+	fresh := make(map[string]cachedJWK, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		fresh[k.Kid] = cachedJWK{alg: k.Alg, key: key, fetchedAt: now}
+	}
+
+	// Replace the cache wholesale rather than upserting: a kid the issuer
+	// has stopped publishing (rotated out/revoked) must stop validating
+	// after this refresh, not keep verifying forever.
+	p.mu.Lock()
+	p.cache = fresh
+	p.mu.Unlock()
+	return nil
+}
+
+// publicKey converts a JWK entry into a crypto.PublicKey usable by the
+// corresponding jwt-go signing method.
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64URLBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64URLInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: e}, nil
+	case "EC":
+		curve, err := ecCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := base64URLBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64URLBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwks: unsupported OKP curve %q", k.Crv)
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: decoding Ed25519 key: %w", err)
+		}
+		return ed25519.PublicKey(raw), nil
+	default:
+		return nil, fmt.Errorf("jwks: unsupported key type %q", k.Kty)
+	}
+}
+
+func ecCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("jwks: unsupported EC curve %q", crv)
+	}
+}
+
+func base64URLBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: decoding base64url value: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func base64URLInt(s string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, fmt.Errorf("jwks: decoding base64url value: %w", err)
+	}
+	n := new(big.Int).SetBytes(b)
+	return int(n.Int64()), nil
+}
+
+// signingMethodEdDSA implements jwt.SigningMethod for EdDSA (Ed25519), which
+// github.com/dgrijalva/jwt-go does not ship out of the box.
+type signingMethodEdDSA struct{}
+
+func (signingMethodEdDSA) Alg() string { return "EdDSA" }
+
+func (signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+func (signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	sig := ed25519.Sign(priv, []byte(signingString))
+	return jwt.EncodeSegment(sig), nil
+}
+
+// eddsaMethod is the shared EdDSA signing method instance, registered with
+// jwt-go so `alg: EdDSA` tokens can be parsed.
+var eddsaMethod = signingMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod("EdDSA", func() jwt.SigningMethod { return eddsaMethod })
+}
+
+// jwksAlgMethods maps the `alg` header value to the jwt-go signing method
+// families it is allowed to verify against, guarding against alg-confusion
+// attacks where a token claims one algorithm but is checked with another.
+var jwksAlgMethods = map[string]func() jwt.SigningMethod{
+	"RS256": func() jwt.SigningMethod { return jwt.SigningMethodRS256 },
+	"RS384": func() jwt.SigningMethod { return jwt.SigningMethodRS384 },
+	"RS512": func() jwt.SigningMethod { return jwt.SigningMethodRS512 },
+	"ES256": func() jwt.SigningMethod { return jwt.SigningMethodES256 },
+	"ES384": func() jwt.SigningMethod { return jwt.SigningMethodES384 },
+	"ES512": func() jwt.SigningMethod { return jwt.SigningMethodES512 },
+	"EdDSA": func() jwt.SigningMethod { return eddsaMethod },
+}
+
+// JwtJWKS is a JSON Web Token middleware that verifies tokens signed with
+// RS256/RS384/RS512/ES256/ES384/ES512/EdDSA using public keys fetched from a
+// remote JWKS endpoint. Keys are cached by `kid` and refreshed on a cache
+// miss; concurrent refreshes for the same miss are coalesced into a single
+// request to the issuer.
+func JwtJWKS(issuerURL string, audiences []string, opts ...JWKSOption) Middleware {
+	cfg := jwksConfig{
+		jwksURL:    issuerURL + defaultJWKSPath,
+		httpClient: http.DefaultClient,
+		cacheTTL:   defaultJWKSCacheTTL,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	provider := newJWKSProvider(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bearer, ok := ExtractBearer(r, WithHeader())
+			if !ok {
+				http.Error(w, "no JSON web token in request", http.StatusUnauthorized)
+				return
+			}
+
+			claims := jwt.MapClaims{}
+			// SkipClaimsValidation: jwt.MapClaims.Valid() enforces exp/nbf/iat
+			// with zero tolerance, which would reject a token WithClockSkew
+			// is meant to allow before validateClaims below ever runs its
+			// skew-aware check. We validate exp/nbf/iat ourselves instead.
+			parser := &jwt.Parser{SkipClaimsValidation: true}
+			token, err := parser.ParseWithClaims(bearer, claims, func(token *jwt.Token) (interface{}, error) {
+				alg, _ := token.Header["alg"].(string)
+				kid, _ := token.Header["kid"].(string)
+				if kid == "" {
+					return nil, fmt.Errorf("jwks: token has no kid header")
+				}
+
+				key, declaredAlg, err := provider.keyForKID(kid)
+				if err != nil {
+					return nil, err
+				}
+				// reject alg-confusion: the token's header alg must match
+				// both a known method and the key's own declared alg, when
+				// the JWK publishes one.
+				if declaredAlg != "" && declaredAlg != alg {
+					return nil, fmt.Errorf("jwks: token alg %q does not match key alg %q", alg, declaredAlg)
+				}
+				if _, ok := jwksAlgMethods[alg]; !ok {
+					return nil, fmt.Errorf("jwks: unsupported alg %q", alg)
+				}
+				if token.Method.Alg() != alg {
+					return nil, fmt.Errorf("jwks: unexpected signing method: %v", alg)
+				}
+				return key, nil
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if !token.Valid {
+				http.Error(w, "token is invalid", http.StatusForbidden)
+				return
+			}
+			if err := validateClaims(claims, issuerURL, audiences, cfg.clockSkew); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+		})
+	}
+}
+
+// validateClaims checks iss, aud and exp/nbf/iat, widening the latter three
+// by skew to tolerate clock drift between the issuer and this service. The
+// library's own claims validation is skipped (see SkipClaimsValidation
+// above), so this function is the only place exp/nbf/iat are enforced.
+func validateClaims(claims jwt.MapClaims, issuer string, audiences []string, skew time.Duration) error {
+	if !claims.VerifyIssuer(issuer, true) {
+		return fmt.Errorf("jwks: unexpected issuer")
+	}
+	if len(audiences) > 0 {
+		var ok bool
+		for _, aud := range audiences {
+			if claims.VerifyAudience(aud, true) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("jwks: unexpected audience")
+		}
+	}
+
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.Add(-skew).Unix() > int64(exp) {
+		return fmt.Errorf("jwks: token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(skew).Unix() < int64(nbf) {
+		return fmt.Errorf("jwks: token used before not-before")
+	}
+	if iat, ok := claims["iat"].(float64); ok && now.Add(skew).Unix() < int64(iat) {
+		return fmt.Errorf("jwks: token used before issued-at")
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package mw
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// jwksTestKey is one RSA key served by newJWKSTestServer.
+type jwksTestKey struct {
+	kid string
+	pub *rsa.PublicKey
+}
+
+func (k jwksTestKey) toJWK() jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: k.kid,
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(k.pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(bigEndianInt(k.pub.E)),
+	}
+}
+
+func bigEndianInt(v int) []byte {
+	b := []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+// newJWKSTestServer serves whatever keys are currently set via setKeys, and
+// counts how many times it was hit.
+type jwksTestServer struct {
+	*httptest.Server
+	hits *int64
+
+	mu   sync.Mutex
+	keys []jwksTestKey
+}
+
+func newJWKSTestServer(t *testing.T) *jwksTestServer {
+	t.Helper()
+	s := &jwksTestServer{hits: new(int64)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(s.hits, 1)
+		s.mu.Lock()
+		keys := s.keys
+		s.mu.Unlock()
+
+		set := jwkSet{}
+		for _, k := range keys {
+			set.Keys = append(set.Keys, k.toJWK())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksTestServer) setKeys(keys ...jwksTestKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksTestServer) fetchCount() int64 {
+	return atomic.LoadInt64(s.hits)
+}
+
+func newRSATestKey(t *testing.T, kid string) (jwksTestKey, *rsa.PrivateKey) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+	return jwksTestKey{kid: kid, pub: &priv.PublicKey}, priv
+}
+
+func signRS256(t *testing.T, priv *rsa.PrivateKey, kid string) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"iss": testIssuer,
+		"aud": "aud1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(priv)
+	if err != nil {
+		t.Fatalf("signing test token: %v", err)
+	}
+	return signed
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJwtJWKSFetchesAndCachesWithinTTL(t *testing.T) {
+	server := newJWKSTestServer(t)
+	k1, priv1 := newRSATestKey(t, "k1")
+	server.setKeys(k1)
+
+	mwFn := JwtJWKS(testIssuer, []string{"aud1"}, WithJWKSURL(server.URL), WithJWKSCacheTTL(time.Hour))
+	token := signRS256(t, priv1, "k1")
+
+	for i := 0; i < 3; i++ {
+		if code := serveJWKS(mwFn, bearerRequest(token)); code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, code)
+		}
+	}
+	if got := server.fetchCount(); got != 1 {
+		t.Fatalf("expected exactly 1 fetch while the cache is within TTL, got %d", got)
+	}
+}
+
+func TestJwtJWKSRefetchesAfterTTLExpiry(t *testing.T) {
+	server := newJWKSTestServer(t)
+	k1, priv1 := newRSATestKey(t, "k1")
+	server.setKeys(k1)
+
+	const ttl = 20 * time.Millisecond
+	mwFn := JwtJWKS(testIssuer, []string{"aud1"}, WithJWKSURL(server.URL), WithJWKSCacheTTL(ttl))
+	token := signRS256(t, priv1, "k1")
+
+	if code := serveJWKS(mwFn, bearerRequest(token)); code != http.StatusOK {
+		t.Fatalf("expected 200 on first request, got %d", code)
+	}
+	if got := server.fetchCount(); got != 1 {
+		t.Fatalf("expected 1 fetch after the first request, got %d", got)
+	}
+
+	time.Sleep(2 * ttl)
+
+	if code := serveJWKS(mwFn, bearerRequest(token)); code != http.StatusOK {
+		t.Fatalf("expected 200 on second request, got %d", code)
+	}
+	if got := server.fetchCount(); got != 2 {
+		t.Fatalf("expected a second fetch once the TTL expired, got %d", got)
+	}
+}
+
+func TestJwtJWKSEvictsRevokedKeysAfterRefresh(t *testing.T) {
+	server := newJWKSTestServer(t)
+	k1, priv1 := newRSATestKey(t, "k1")
+	k2, _ := newRSATestKey(t, "k2")
+	server.setKeys(k1, k2)
+
+	const ttl = 20 * time.Millisecond
+	mwFn := JwtJWKS(testIssuer, []string{"aud1"}, WithJWKSURL(server.URL), WithJWKSCacheTTL(ttl))
+	token := signRS256(t, priv1, "k1")
+
+	if code := serveJWKS(mwFn, bearerRequest(token)); code != http.StatusOK {
+		t.Fatalf("expected 200 while k1 is published, got %d", code)
+	}
+
+	// Simulate the issuer rotating k1 out: it no longer appears in the JWKS.
+	server.setKeys(k2)
+	time.Sleep(2 * ttl)
+
+	if code := serveJWKS(mwFn, bearerRequest(token)); code != http.StatusForbidden {
+		t.Fatalf("expected a token signed with a revoked, no-longer-published key to be rejected after refresh, got %d", code)
+	}
+}
+
+func TestJwtJWKSCoalescesConcurrentRefreshes(t *testing.T) {
+	server := newJWKSTestServer(t)
+	k1, priv1 := newRSATestKey(t, "k1")
+	server.setKeys(k1)
+
+	mwFn := JwtJWKS(testIssuer, []string{"aud1"}, WithJWKSURL(server.URL), WithJWKSCacheTTL(time.Hour))
+	token := signRS256(t, priv1, "k1")
+
+	const concurrency = 20
+	var wg sync.WaitGroup
+	codes := make([]int, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = serveJWKS(mwFn, bearerRequest(token))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d: expected 200, got %d", i, code)
+		}
+	}
+	if got := server.fetchCount(); got != 1 {
+		t.Fatalf("expected concurrent cache misses to coalesce into exactly 1 fetch, got %d", got)
+	}
+}
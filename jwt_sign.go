@@ -0,0 +1,186 @@
+package mw
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// signDestination selects where JwtIssue writes the signed token.
+type signDestination int
+
+const (
+	destBody signDestination = iota
+	destHeader
+	destCookie
+)
+
+// signConfig holds the options collected from SignOption values.
+type signConfig struct {
+	kid string
+
+	defaultIssuer   string
+	defaultAudience string
+	defaultTTL      time.Duration
+
+	signingKeyFunc func() (interface{}, error)
+
+	destination signDestination
+	cookieName  string
+}
+
+// SignOption configures JwtSign and JwtIssue.
+type SignOption func(*signConfig)
+
+// WithKeyID sets the `kid` header on the signed token, so the rotation-aware
+// verifiers (StaticKeys, JwtJWKS) can pick the matching key.
+func WithKeyID(kid string) SignOption {
+	return func(c *signConfig) { c.kid = kid }
+}
+
+// WithDefaultClaims auto-populates `iss`, `aud`, `iat`, `exp` and `nbf` on
+// the signed token when its claims are a jwt.MapClaims; `exp` is set to
+// now+ttl. Claims that are already set on the value passed to the signer
+// are left untouched.
+func WithDefaultClaims(iss, aud string, ttl time.Duration) SignOption {
+	return func(c *signConfig) {
+		c.defaultIssuer = iss
+		c.defaultAudience = aud
+		c.defaultTTL = ttl
+	}
+}
+
+// WithSigningKeyFunc defers key resolution to f, called on every signing
+// operation, for HSM/KMS-backed keys that shouldn't be held in memory as a
+// plain value.
+func WithSigningKeyFunc(f func() (interface{}, error)) SignOption {
+	return func(c *signConfig) { c.signingKeyFunc = f }
+}
+
+// AsResponseBody writes the signed token as the raw response body. This is
+// the default destination for JwtIssue.
+func AsResponseBody() SignOption {
+	return func(c *signConfig) { c.destination = destBody }
+}
+
+// AsAuthorizationHeader writes the signed token to the response as an
+// `Authorization: Bearer <token>` header.
+func AsAuthorizationHeader() SignOption {
+	return func(c *signConfig) { c.destination = destHeader }
+}
+
+// AsCookie writes the signed token to the response as a cookie with the
+// given name.
+func AsCookie(name string) SignOption {
+	return func(c *signConfig) {
+		c.destination = destCookie
+		c.cookieName = name
+	}
+}
+
+// JwtSign returns a function that signs claims with method and key,
+// applying the given options on every call. Use WithSigningKeyFunc instead
+// of a fixed key to resolve the key lazily, e.g. from an HSM or KMS.
+func JwtSign(method jwt.SigningMethod, key interface{}, opts ...SignOption) func(claims Claims) (string, error) {
+	cfg := signConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(claims Claims) (string, error) {
+		applyDefaultClaims(claims, cfg)
+
+		token := jwt.NewWithClaims(method, claims)
+		if cfg.kid != "" {
+			token.Header["kid"] = cfg.kid
+		}
+
+		signingKey := key
+		if cfg.signingKeyFunc != nil {
+			var err error
+			signingKey, err = cfg.signingKeyFunc()
+			if err != nil {
+				return "", fmt.Errorf("jwt-sign: resolving signing key: %w", err)
+			}
+		}
+
+		signed, err := token.SignedString(signingKey)
+		if err != nil {
+			return "", fmt.Errorf("jwt-sign: signing token: %w", err)
+		}
+		return signed, nil
+	}
+}
+
+// applyDefaultClaims fills iss/aud/iat/exp/nbf on claims when it is a
+// jwt.MapClaims and WithDefaultClaims was configured; other Claims
+// implementations are left to populate their own fields.
+func applyDefaultClaims(claims Claims, cfg signConfig) {
+	if cfg.defaultTTL == 0 && cfg.defaultIssuer == "" && cfg.defaultAudience == "" {
+		return
+	}
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if _, ok := mapClaims["iat"]; !ok {
+		mapClaims["iat"] = now.Unix()
+	}
+	if _, ok := mapClaims["nbf"]; !ok {
+		mapClaims["nbf"] = now.Unix()
+	}
+	if _, ok := mapClaims["exp"]; !ok && cfg.defaultTTL > 0 {
+		mapClaims["exp"] = now.Add(cfg.defaultTTL).Unix()
+	}
+	if _, ok := mapClaims["iss"]; !ok && cfg.defaultIssuer != "" {
+		mapClaims["iss"] = cfg.defaultIssuer
+	}
+	if _, ok := mapClaims["aud"]; !ok && cfg.defaultAudience != "" {
+		mapClaims["aud"] = cfg.defaultAudience
+	}
+}
+
+// JwtIssue is a middleware that signs the claims placed in the request
+// context (via ContextWithClaims, typically by a handler upstream that just
+// validated credentials) and writes the resulting token to the response as
+// configured by opts: the response body by default, an `Authorization:
+// Bearer <token>` header (AsAuthorizationHeader), or a cookie (AsCookie).
+// It responds 500 if no claims are present in the context.
+func JwtIssue(method jwt.SigningMethod, key interface{}, opts ...SignOption) Middleware {
+	cfg := signConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	sign := JwtSign(method, key, opts...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := GetClaimsFromContext(r.Context())
+			if claims == nil {
+				http.Error(w, "no claims to sign in request context", http.StatusInternalServerError)
+				return
+			}
+
+			token, err := sign(claims)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			switch cfg.destination {
+			case destHeader:
+				w.Header().Set("Authorization", "Bearer "+token)
+				next.ServeHTTP(w, r)
+			case destCookie:
+				http.SetCookie(w, &http.Cookie{Name: cfg.cookieName, Value: token, Path: "/", HttpOnly: true})
+				next.ServeHTTP(w, r)
+			default:
+				w.Write([]byte(token))
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package mw
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestJwtSignWithDefaultClaimsDoesNotClobberSetClaims(t *testing.T) {
+	explicitIat := time.Now().Add(-time.Hour).Unix()
+	claims := jwt.MapClaims{
+		"sub": "alice",
+		"iss": "already-set-issuer",
+		"iat": explicitIat,
+	}
+
+	sign := JwtSign(jwt.SigningMethodHS256, []byte("secret"), WithDefaultClaims("default-issuer", "default-aud", time.Hour))
+	if _, err := sign(claims); err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+
+	if claims["iss"] != "already-set-issuer" {
+		t.Fatalf("expected an already-set iss to be left untouched, got %v", claims["iss"])
+	}
+	if claims["iat"] != explicitIat {
+		t.Fatalf("expected an already-set iat to be left untouched, got %v", claims["iat"])
+	}
+	if claims["aud"] != "default-aud" {
+		t.Fatalf("expected aud to be defaulted, got %v", claims["aud"])
+	}
+	if _, ok := claims["exp"]; !ok {
+		t.Fatal("expected exp to be defaulted from the ttl")
+	}
+}
+
+func TestJwtSignWithKeyIDSetsHeader(t *testing.T) {
+	sign := JwtSign(jwt.SigningMethodHS256, []byte("secret"), WithKeyID("k1"))
+	signed, err := sign(jwt.MapClaims{"sub": "alice"})
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+
+	token, _, err := new(jwt.Parser).ParseUnverified(signed, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("parsing signed token: %v", err)
+	}
+	if kid, _ := token.Header["kid"].(string); kid != "k1" {
+		t.Fatalf("expected kid header %q, got %q", "k1", kid)
+	}
+}
+
+func TestJwtSignWithSigningKeyFuncErrorPropagates(t *testing.T) {
+	wantErr := errors.New("kms unavailable")
+	sign := JwtSign(jwt.SigningMethodHS256, nil, WithSigningKeyFunc(func() (interface{}, error) {
+		return nil, wantErr
+	}))
+
+	_, err := sign(jwt.MapClaims{"sub": "alice"})
+	if err == nil || !errors.Is(err, wantErr) {
+		t.Fatalf("expected the signing key func's error to propagate, got %v", err)
+	}
+}
+
+func issueRequest(t *testing.T, mwFn Middleware, claims Claims) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	nextCalled := false
+	handler := mwFn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalled = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req = req.WithContext(ContextWithClaims(req.Context(), claims))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, nextCalled
+}
+
+func TestJwtIssueDestinations(t *testing.T) {
+	claims := jwt.MapClaims{"sub": "alice"}
+
+	t.Run("body destination writes the token as the body and does not call next", func(t *testing.T) {
+		mwFn := JwtIssue(jwt.SigningMethodHS256, []byte("secret"), AsResponseBody())
+		rec, nextCalled := issueRequest(t, mwFn, claims)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if rec.Body.Len() == 0 {
+			t.Fatal("expected a non-empty token in the response body")
+		}
+		if nextCalled {
+			t.Fatal("expected the body destination to end the chain without calling next")
+		}
+	})
+
+	t.Run("header destination writes Authorization and calls next", func(t *testing.T) {
+		mwFn := JwtIssue(jwt.SigningMethodHS256, []byte("secret"), AsAuthorizationHeader())
+		rec, nextCalled := issueRequest(t, mwFn, claims)
+
+		got := rec.Header().Get("Authorization")
+		if !strings.HasPrefix(got, "Bearer ") {
+			t.Fatalf("expected an Authorization: Bearer header, got %q", got)
+		}
+		if !nextCalled {
+			t.Fatal("expected the header destination to call next")
+		}
+	})
+
+	t.Run("cookie destination writes a cookie and calls next", func(t *testing.T) {
+		mwFn := JwtIssue(jwt.SigningMethodHS256, []byte("secret"), AsCookie("session"))
+		rec, nextCalled := issueRequest(t, mwFn, claims)
+
+		resp := rec.Result()
+		var found *http.Cookie
+		for _, c := range resp.Cookies() {
+			if c.Name == "session" {
+				found = c
+			}
+		}
+		if found == nil || found.Value == "" {
+			t.Fatal("expected a non-empty session cookie")
+		}
+		if !nextCalled {
+			t.Fatal("expected the cookie destination to call next")
+		}
+	})
+}
+
+func TestJwtIssueNoClaimsInContext(t *testing.T) {
+	mwFn := JwtIssue(jwt.SigningMethodHS256, []byte("secret"))
+	handler := mwFn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next must not be called when there are no claims to sign")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when no claims are in context, got %d", rec.Code)
+	}
+}
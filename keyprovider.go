@@ -0,0 +1,130 @@
+package mw
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// KeyProvider resolves the verification key for a token, mirroring
+// jwt.Keyfunc's signature so it can be passed straight to
+// jwt.ParseWithClaims. Implementations are expected to validate that the
+// token's `alg` header agrees with whatever key they return, rejecting the
+// mismatch before returning so signature verification never runs against
+// the wrong algorithm.
+type KeyProvider interface {
+	KeyFunc(token *jwt.Token) (interface{}, error)
+}
+
+// StaticKeyEntry is a single key known to a StaticKeys provider.
+type StaticKeyEntry struct {
+	// Kid identifies the key, matched against the token's `kid` header. May
+	// be left empty for deployments that don't set one, in which case the
+	// key is only considered via the Algorithm fallback below.
+	Kid string
+	// Algorithm is the jwt-go alg name (e.g. "HS256") this key is valid for.
+	// Required: a blank Algorithm is rejected by KeyFunc rather than treated
+	// as a wildcard, so a key can never be matched against a token signed
+	// with a different algorithm than intended.
+	Algorithm string
+	// Key is the raw key material passed to the signing method's Verify,
+	// e.g. a []byte secret or an *rsa.PublicKey.
+	Key interface{}
+}
+
+// StaticKeys is a KeyProvider backed by a fixed, in-memory list of keys.
+// It picks the entry whose Kid matches the token header; when the token
+// carries no kid, it falls back to trying every entry whose Algorithm
+// matches the token's signing method, so operators can rotate a secret by
+// publishing the new key alongside the old one and dropping the old one
+// once outstanding tokens have expired.
+type StaticKeys []StaticKeyEntry
+
+// KeyFunc implements KeyProvider.
+func (keys StaticKeys) KeyFunc(token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+	kid, _ := token.Header["kid"].(string)
+
+	if kid != "" {
+		for _, entry := range keys {
+			if entry.Kid != kid {
+				continue
+			}
+			if entry.Algorithm == "" {
+				return nil, fmt.Errorf("key-provider: key %q has no Algorithm configured", kid)
+			}
+			if entry.Algorithm != alg {
+				return nil, fmt.Errorf("key-provider: token alg %q does not match key %q alg %q", alg, kid, entry.Algorithm)
+			}
+			return entry.Key, nil
+		}
+		return nil, fmt.Errorf("key-provider: no key found for kid %q", kid)
+	}
+
+	return keys.firstVerifying(token, alg)
+}
+
+// firstVerifying tries every entry matching alg against the token's actual
+// signature, returning the first key that verifies. This is only reachable
+// for tokens without a kid header, where KeyFunc can't pick a single
+// candidate up front.
+func (keys StaticKeys) firstVerifying(token *jwt.Token, alg string) (interface{}, error) {
+	parts := strings.Split(token.Raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("key-provider: malformed token")
+	}
+	signingString := parts[0] + "." + parts[1]
+
+	var tried int
+	for _, entry := range keys {
+		if entry.Algorithm != alg {
+			continue
+		}
+		tried++
+		if err := token.Method.Verify(signingString, parts[2], entry.Key); err == nil {
+			return entry.Key, nil
+		}
+	}
+	if tried == 0 {
+		return nil, fmt.Errorf("key-provider: no key configured for alg %q", alg)
+	}
+	return nil, fmt.Errorf("key-provider: no key for alg %q verified the token", alg)
+}
+
+// JwtWithKeyProvider is a JSON Web Token middleware parameterized by a
+// KeyProvider, letting callers manage multiple keys (e.g. for rotation)
+// instead of a single fixed secret.
+func JwtWithKeyProvider(keys KeyProvider, cf func() Claims) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bearer, ok := ExtractBearer(r, WithHeader())
+			if !ok {
+				http.Error(w, "no JSON web token in request", http.StatusUnauthorized)
+				return
+			}
+			token, err := jwt.ParseWithClaims(bearer, cf(), keys.KeyFunc)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if !token.Valid {
+				http.Error(w, "token is invalid", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, token.Claims)))
+		})
+	}
+}
+
+// JwtHS256 is a JSON Web token middleware using HMAC signing method that parses
+// token to the provided Claims receiver and puts it to the request context.
+//
+// It is a thin wrapper over JwtWithKeyProvider for callers with a single
+// static secret; use JwtWithKeyProvider with StaticKeys directly to rotate
+// secrets.
+func JwtHS256(secret string, cf func() Claims) Middleware {
+	return JwtWithKeyProvider(StaticKeys{{Algorithm: "HS256", Key: []byte(secret)}}, cf)
+}
@@ -0,0 +1,68 @@
+package mw
+
+import (
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestStaticKeysKeyFunc(t *testing.T) {
+	secret := []byte("top-secret")
+
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice"})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatalf("signing test token: %v", err)
+		}
+		return signed
+	}
+
+	t.Run("matches by kid", func(t *testing.T) {
+		keys := StaticKeys{{Kid: "k1", Algorithm: "HS256", Key: secret}}
+		if _, err := jwt.Parse(sign("k1"), keys.KeyFunc); err != nil {
+			t.Fatalf("expected token to verify, got: %v", err)
+		}
+	})
+
+	t.Run("rejects alg mismatch for matching kid", func(t *testing.T) {
+		keys := StaticKeys{{Kid: "k1", Algorithm: "RS256", Key: secret}}
+		if _, err := jwt.Parse(sign("k1"), keys.KeyFunc); err == nil {
+			t.Fatal("expected alg mismatch to be rejected")
+		}
+	})
+
+	t.Run("rejects blank Algorithm instead of treating it as a wildcard", func(t *testing.T) {
+		keys := StaticKeys{{Kid: "k1", Key: secret}}
+		if _, err := jwt.Parse(sign("k1"), keys.KeyFunc); err == nil {
+			t.Fatal("expected a key with no Algorithm to be rejected, not matched")
+		}
+	})
+
+	t.Run("falls back to trying every key of the matching algorithm when kid is absent", func(t *testing.T) {
+		keys := StaticKeys{
+			{Kid: "old", Algorithm: "HS256", Key: []byte("stale-secret")},
+			{Kid: "new", Algorithm: "HS256", Key: secret},
+		}
+		if _, err := jwt.Parse(sign(""), keys.KeyFunc); err != nil {
+			t.Fatalf("expected rotation fallback to find the matching key, got: %v", err)
+		}
+	})
+
+	t.Run("fallback rejects when no key verifies", func(t *testing.T) {
+		keys := StaticKeys{{Kid: "old", Algorithm: "HS256", Key: []byte("wrong-secret")}}
+		if _, err := jwt.Parse(sign(""), keys.KeyFunc); err == nil {
+			t.Fatal("expected fallback to reject a token none of the keys verify")
+		}
+	})
+
+	t.Run("unknown kid is rejected", func(t *testing.T) {
+		keys := StaticKeys{{Kid: "k1", Algorithm: "HS256", Key: secret}}
+		if _, err := jwt.Parse(sign("unknown"), keys.KeyFunc); err == nil {
+			t.Fatal("expected an unknown kid to be rejected")
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package mw
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultIntrospectionTimeout bounds how long a single introspection request
+// to the authorization server is allowed to take.
+const defaultIntrospectionTimeout = 10 * time.Second
+
+// defaultIntrospectionCacheMaxAge caps how long an introspection result is
+// trusted when the response carries no `exp` claim.
+const defaultIntrospectionCacheMaxAge = time.Minute
+
+// IntrospectionAuthMethod selects how the middleware authenticates itself to
+// the introspection endpoint, per RFC 7662 section 2.1.
+type IntrospectionAuthMethod string
+
+const (
+	// ClientSecretBasic sends ClientID/ClientSecret as HTTP Basic auth.
+	ClientSecretBasic IntrospectionAuthMethod = "client_secret_basic"
+	// ClientAuthNone sends no client authentication at all.
+	ClientAuthNone IntrospectionAuthMethod = "none"
+)
+
+// IntrospectionConfig configures OAuth2Introspect.
+type IntrospectionConfig struct {
+	// IntrospectionURL is the RFC 7662 token introspection endpoint.
+	IntrospectionURL string
+	// ClientID and ClientSecret authenticate this middleware to the
+	// introspection endpoint when AuthMethod is ClientSecretBasic.
+	ClientID     string
+	ClientSecret string
+	// AuthMethod selects the client authentication scheme. Defaults to
+	// ClientSecretBasic when ClientID is set, ClientAuthNone otherwise.
+	AuthMethod IntrospectionAuthMethod
+
+	// HTTPClient is used to call the introspection endpoint. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// Timeout bounds each introspection request. Defaults to 10s.
+	Timeout time.Duration
+	// MaxCacheAge bounds how long a result is cached when the response has
+	// no `exp` claim, and caps the cache lifetime even when it does.
+	// Defaults to 1 minute.
+	MaxCacheAge time.Duration
+}
+
+// introspectionKey is the request context key for the introspection payload.
+type introspectionKey struct{}
+
+// IntrospectionResult is the decoded RFC 7662 introspection response body,
+// e.g. sub, scope, exp, username, aud and any custom fields the
+// authorization server adds.
+type IntrospectionResult map[string]interface{}
+
+// Active reports the `active` field of the introspection response.
+func (r IntrospectionResult) Active() bool {
+	active, _ := r["active"].(bool)
+	return active
+}
+
+// introspectionCacheEntry is a single cached introspection result.
+type introspectionCacheEntry struct {
+	result    IntrospectionResult
+	expiresAt time.Time
+}
+
+// introspectionCache is a small in-process TTL cache keyed by the SHA-256
+// hash of the bearer token, so repeated requests for the same token within
+// its cache window avoid a round-trip to the authorization server.
+type introspectionCache struct {
+	mu      sync.Mutex
+	entries map[string]introspectionCacheEntry
+}
+
+func newIntrospectionCache() *introspectionCache {
+	return &introspectionCache{entries: make(map[string]introspectionCacheEntry)}
+}
+
+func (c *introspectionCache) get(key string) (IntrospectionResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+func (c *introspectionCache) set(key string, result IntrospectionResult, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = introspectionCacheEntry{result: result, expiresAt: expiresAt}
+}
+
+func tokenCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// OAuth2Introspect is a middleware that authorizes requests carrying an
+// opaque OAuth2 access token by calling an RFC 7662 token introspection
+// endpoint. On success the full introspection payload (sub, scope, exp,
+// username, aud and any custom fields) is stored in the request context and
+// retrievable via GetIntrospectionFromContext; otherwise it responds 401.
+func OAuth2Introspect(cfg IntrospectionConfig) Middleware {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultIntrospectionTimeout
+	}
+	if cfg.MaxCacheAge == 0 {
+		cfg.MaxCacheAge = defaultIntrospectionCacheMaxAge
+	}
+	if cfg.AuthMethod == "" {
+		cfg.AuthMethod = ClientAuthNone
+		if cfg.ClientID != "" {
+			cfg.AuthMethod = ClientSecretBasic
+		}
+	}
+
+	cache := newIntrospectionCache()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bearer, ok := ExtractBearer(r, WithHeader())
+			if !ok {
+				http.Error(w, "no bearer token in request", http.StatusUnauthorized)
+				return
+			}
+
+			cacheKey := tokenCacheKey(bearer)
+			result, ok := cache.get(cacheKey)
+			if !ok {
+				var err error
+				result, err = cfg.introspect(r.Context(), bearer)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				cache.set(cacheKey, result, cfg.expiryFor(result))
+			}
+
+			if !result.Active() {
+				http.Error(w, "token is not active", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), introspectionKey{}, result)))
+		})
+	}
+}
+
+// introspect calls the introspection endpoint for the given bearer token.
+func (cfg IntrospectionConfig) introspect(ctx context.Context, bearer string) (IntrospectionResult, error) {
+	form := url.Values{
+		"token":           {bearer},
+		"token_type_hint": {"access_token"},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.IntrospectionURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("introspection: building request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cfg.AuthMethod == ClientSecretBasic {
+		req.SetBasicAuth(cfg.ClientID, cfg.ClientSecret)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("introspection: calling %s: %w", cfg.IntrospectionURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection: unexpected status %d", resp.StatusCode)
+	}
+
+	var result IntrospectionResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("introspection: decoding response: %w", err)
+	}
+	return result, nil
+}
+
+// expiryFor computes the cache deadline for a result, bounded by both the
+// response's own `exp` claim and MaxCacheAge.
+func (cfg IntrospectionConfig) expiryFor(result IntrospectionResult) time.Time {
+	deadline := time.Now().Add(cfg.MaxCacheAge)
+	exp, ok := result["exp"].(float64)
+	if !ok {
+		return deadline
+	}
+	expiresAt := time.Unix(int64(exp), 0)
+	if expiresAt.Before(deadline) {
+		return expiresAt
+	}
+	return deadline
+}
+
+// GetIntrospectionFromContext returns the introspection payload stored by
+// OAuth2Introspect.
+func GetIntrospectionFromContext(ctx context.Context) IntrospectionResult {
+	result, _ := ctx.Value(introspectionKey{}).(IntrospectionResult)
+	return result
+}
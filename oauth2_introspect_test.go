@@ -0,0 +1,221 @@
+package mw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// introspectionTestServer is a stand-in RFC 7662 introspection endpoint that
+// records how it was called and how many times it was hit.
+type introspectionTestServer struct {
+	*httptest.Server
+	hits *int64
+
+	activeToken  string
+	extraExpInMs int64 // when > 0, the active response carries exp = now + this many ms, in whole Unix seconds
+
+	lastAuthHeader  string
+	lastContentType string
+	lastTokenParam  string
+}
+
+func newIntrospectionTestServer(t *testing.T, activeToken string) *introspectionTestServer {
+	t.Helper()
+	s := &introspectionTestServer{hits: new(int64), activeToken: activeToken}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(s.hits, 1)
+
+		s.lastAuthHeader = r.Header.Get("Authorization")
+		s.lastContentType = r.Header.Get("Content-Type")
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("introspection server: parsing form: %v", err)
+		}
+		s.lastTokenParam = r.PostForm.Get("token")
+		if r.PostForm.Get("token_type_hint") != "access_token" {
+			t.Fatalf("introspection server: expected token_type_hint=access_token, got %q", r.PostForm.Get("token_type_hint"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if s.lastTokenParam != s.activeToken {
+			w.Write([]byte(`{"active":false}`))
+			return
+		}
+
+		body := `{"active":true,"sub":"alice","scope":"read write"`
+		if s.extraExpInMs > 0 {
+			exp := time.Now().Add(time.Duration(s.extraExpInMs) * time.Millisecond).Unix()
+			body += `,"exp":` + itoa(exp)
+		}
+		body += `}`
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func itoa(v int64) string {
+	if v == 0 {
+		return "0"
+	}
+	neg := v < 0
+	if neg {
+		v = -v
+	}
+	var buf [20]byte
+	i := len(buf)
+	for v > 0 {
+		i--
+		buf[i] = byte('0' + v%10)
+		v /= 10
+	}
+	if neg {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
+
+func (s *introspectionTestServer) hitCount() int64 {
+	return atomic.LoadInt64(s.hits)
+}
+
+func TestOAuth2IntrospectActiveAndInactive(t *testing.T) {
+	server := newIntrospectionTestServer(t, "good-token")
+	mwFn := OAuth2Introspect(IntrospectionConfig{IntrospectionURL: server.URL})
+
+	var gotResult IntrospectionResult
+	handler := mwFn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotResult = GetIntrospectionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("active token is authorized", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer good-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200 for an active token, got %d", rec.Code)
+		}
+		if gotResult == nil || !gotResult.Active() || gotResult["sub"] != "alice" {
+			t.Fatalf("expected the introspection payload in context, got %#v", gotResult)
+		}
+	})
+
+	t.Run("inactive token is rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer revoked-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for an inactive token, got %d", rec.Code)
+		}
+	})
+}
+
+func TestOAuth2IntrospectAuthMethods(t *testing.T) {
+	t.Run("client_secret_basic sends HTTP basic auth", func(t *testing.T) {
+		server := newIntrospectionTestServer(t, "good-token")
+		mwFn := OAuth2Introspect(IntrospectionConfig{
+			IntrospectionURL: server.URL,
+			ClientID:         "client-id",
+			ClientSecret:     "client-secret",
+			AuthMethod:       ClientSecretBasic,
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer good-token")
+		rec := httptest.NewRecorder()
+		mwFn(okHandler()).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if !strings.HasPrefix(server.lastAuthHeader, "Basic ") {
+			t.Fatalf("expected the introspection request to carry HTTP Basic auth, got %q", server.lastAuthHeader)
+		}
+	})
+
+	t.Run("none sends no client authentication", func(t *testing.T) {
+		server := newIntrospectionTestServer(t, "good-token")
+		mwFn := OAuth2Introspect(IntrospectionConfig{
+			IntrospectionURL: server.URL,
+			AuthMethod:       ClientAuthNone,
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer good-token")
+		rec := httptest.NewRecorder()
+		mwFn(okHandler()).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if server.lastAuthHeader != "" {
+			t.Fatalf("expected no Authorization header on the introspection request, got %q", server.lastAuthHeader)
+		}
+	})
+}
+
+func TestOAuth2IntrospectCachesWithinTTL(t *testing.T) {
+	server := newIntrospectionTestServer(t, "good-token")
+	mwFn := OAuth2Introspect(IntrospectionConfig{IntrospectionURL: server.URL, MaxCacheAge: time.Hour})
+	handler := mwFn(okHandler())
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer good-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	if got := server.hitCount(); got != 1 {
+		t.Fatalf("expected a single round-trip to the introspection endpoint, got %d", got)
+	}
+}
+
+func TestOAuth2IntrospectCacheExpiryBoundByExp(t *testing.T) {
+	server := newIntrospectionTestServer(t, "good-token")
+	server.extraExpInMs = 20
+
+	mwFn := OAuth2Introspect(IntrospectionConfig{IntrospectionURL: server.URL, MaxCacheAge: time.Hour})
+	handler := mwFn(okHandler())
+
+	req := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer good-token")
+		return r
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := server.hitCount(); got != 1 {
+		t.Fatalf("expected 1 round-trip, got %d", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 after re-introspecting, got %d", rec.Code)
+	}
+	if got := server.hitCount(); got != 2 {
+		t.Fatalf("expected the cache entry to expire at the response's own exp (well before MaxCacheAge) and trigger a second round-trip, got %d", got)
+	}
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}